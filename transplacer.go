@@ -3,7 +3,11 @@ package mak
 import (
 	"bytes"
 	"compress/gzip"
+	"container/list"
 	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,10 +16,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/cornelk/hashmap"
+	"github.com/fsnotify/fsnotify"
 )
 
 var (
@@ -26,6 +35,23 @@ var (
 // HashMap is an alias of cornelk/hashmap
 type HashMap = hashmap.HashMap
 
+// CacheConfig configures the optional disk-backed cache tier for an
+// AssetCache, sitting between the in-memory hashmap and the source files.
+type CacheConfig struct {
+	// Dir is where generated gzip/brotli bytes and etag metadata are
+	// persisted between process restarts. Leave empty to disable the
+	// disk tier entirely.
+	Dir string
+
+	// MaxAge is how long a disk entry stays valid before Gen re-derives
+	// it from the source file. -1 means forever, 0 disables the tier.
+	MaxAge time.Duration
+
+	// MaxSize caps the total bytes the disk tier may occupy on disk;
+	// once exceeded, the least recently used entries are evicted.
+	MaxSize int64
+}
+
 // AssetCache is a store for assets
 type AssetCache struct {
 	Dir   string
@@ -36,11 +62,27 @@ type AssetCache struct {
 
 	CacheControl string
 
+	DiskCache CacheConfig
+
+	diskMu    sync.Mutex
+	diskOrder *list.List
+	diskElems map[string]*list.Element
+	diskBytes int64
+
+	watcher *fsnotify.Watcher
+
 	Ticker *time.Ticker
 }
 
+// diskLRUEntry tracks one file sitting in the disk cache tier, used to
+// enforce CacheConfig.MaxSize on an LRU basis.
+type diskLRUEntry struct {
+	key  string
+	size int64
+}
+
 // MakeAssetCache prepares a new *AssetCache for use
-func MakeAssetCache(dir string, expire time.Duration, interval time.Duration) (*AssetCache, error) {
+func MakeAssetCache(dir string, expire time.Duration, interval time.Duration, cache CacheConfig) (*AssetCache, error) {
 	dir, err := filepath.Abs(dir)
 	if err != nil {
 		return nil, err
@@ -50,6 +92,18 @@ func MakeAssetCache(dir string, expire time.Duration, interval time.Duration) (*
 		Cache:        &HashMap{},
 		Expire:       expire,
 		CacheControl: "private, must-revalidate",
+		DiskCache:    cache,
+		diskOrder:    list.New(),
+		diskElems:    map[string]*list.Element{},
+	}
+
+	if a.diskEnabled() {
+		if err := os.MkdirAll(a.DiskCache.Dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := a.diskLoadIndex(); err != nil {
+			return nil, err
+		}
 	}
 
 	a.SetInterval(interval)
@@ -65,9 +119,91 @@ func MakeAssetCache(dir string, expire time.Duration, interval time.Duration) (*
 		}
 	}()
 
+	// The watcher is an optional latency optimization on top of the
+	// ticker-based expiry above; if it can't start (e.g. the OS's
+	// inotify watch-count limit), fall back to running without it
+	// rather than failing construction of the whole cache.
+	_ = a.Watch(true)
+
 	return a, err
 }
 
+// Watch starts or stops an fsnotify watcher that recursively follows Dir
+// and invalidates or regenerates the matching cache entry as soon as a
+// file changes, rather than waiting for the next expiry tick.
+func (a *AssetCache) Watch(enable bool) error {
+	if !enable {
+		if a.watcher == nil {
+			return nil
+		}
+		err := a.watcher.Close()
+		a.watcher = nil
+		return err
+	}
+
+	if a.watcher != nil {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(a.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return w.Add(p)
+		}
+		return nil
+	})
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	a.watcher = w
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				a.handleWatchEvent(event)
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// handleWatchEvent reacts to a single fsnotify event by invalidating (and,
+// for writes/creates, eagerly regenerating) the matching cache entry.
+func (a *AssetCache) handleWatchEvent(event fsnotify.Event) {
+	name := strings.TrimPrefix(event.Name, a.Dir)
+
+	switch {
+	case event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+		if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+			a.watcher.Add(event.Name)
+			return
+		}
+		a.Del(name)
+		a.Gen(name)
+
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		a.Del(name)
+	}
+}
+
 // SetInterval generates a new ticker with a set interval
 func (a *AssetCache) SetInterval(interval time.Duration) {
 	if a.Ticker != nil {
@@ -79,13 +215,27 @@ func (a *AssetCache) SetInterval(interval time.Duration) {
 
 // Handler serves the assets
 func (a *AssetCache) Handler(c *Ctx) error {
-	name := path.Clean(a.Dir + c.R.URL.Path)
+	if logical, hash, ok := parseHashedURL(c.R.URL.Path); ok {
+		if asset, found := a.Get(logical); found && strings.HasPrefix(strings.Trim(asset.Etag, `"`), hash) {
+			c.SetHeader("cache-control", "public, max-age=31536000, immutable")
+			return asset.Serve(c)
+		}
+		// Not actually a fingerprinted hit - e.g. the hash is stale, or
+		// the request path just names a literal asset that happens to
+		// look fingerprinted (like "/app.deadbeef.css"). Fall through to a
+		// literal lookup instead of 404ing outright.
+	}
 
-	asset, ok := a.Get(name)
+	asset, ok := a.Get(c.R.URL.Path)
 	if ok {
 		return asset.Serve(c)
 	}
 
+	return a.notFound(c)
+}
+
+// notFound runs the instance's error handler (if any) for a missing asset.
+func (a *AssetCache) notFound(c *Ctx) error {
 	err := ErrNotFound.Envoy(c)
 	if c.instance.ErrorHandler != nil {
 		return c.instance.ErrorHandler(c, err)
@@ -99,7 +249,7 @@ func (a *AssetCache) Close() error {
 	if a.Ticker != nil {
 		a.Ticker.Stop()
 	}
-	return nil
+	return a.Watch(false)
 }
 
 // Gen generates a new Asset
@@ -157,6 +307,24 @@ func (a *AssetCache) Gen(name string) (*Asset, error) {
 		asset.EtagCompressed = fmt.Sprintf(`"%x"`, et)
 
 		asset.ContentCompressed = compressed
+
+		brotlied, err := brotliBytes(content, 9)
+		if err != nil {
+			return nil, err
+		}
+
+		var etBr []byte
+		hBr := sha256.New()
+		_, err = io.Copy(hBr, bytes.NewReader(brotlied))
+		if err != nil {
+			return nil, err
+		}
+		if etBr == nil {
+			etBr = hBr.Sum(nil)
+		}
+		asset.EtagBrotli = fmt.Sprintf(`"%x"`, etBr)
+
+		asset.ContentBrotli = brotlied
 	}
 
 	var et []byte
@@ -173,27 +341,303 @@ func (a *AssetCache) Gen(name string) (*Asset, error) {
 	if err == nil {
 		asset.Loaded = time.Now()
 		a.Cache.Set(name, asset)
+
+		if a.diskEnabled() {
+			// Disk is a best-effort perf optimization on top of a valid
+			// in-memory asset; a write failure here (full disk,
+			// permissions, ...) must not turn a successful read into a
+			// cache miss for the caller.
+			_ = a.diskStore(name, asset)
+		}
 	}
 
 	return asset, err
 }
 
-// Get fetches an asset
+// Get fetches an asset, checking the in-memory cache first, then the
+// disk-backed tier (if configured), before falling back to Gen to read
+// and derive it fresh from the source file. A cached entry is only
+// returned as-is when the source file's mtime still matches the one it
+// was generated from; this is a safety net for when the fsnotify watcher
+// is disabled or hasn't caught up yet.
 func (a *AssetCache) Get(name string) (*Asset, bool) {
-	name = path.Clean(a.Dir + name)
+	// Gen joins name onto a.Dir itself, so keep name as-is for that call
+	// and only join locally (joined) for the cache lookups/stat below -
+	// joining twice would look up a nonexistent <Dir><Dir>/file path.
+	joined := path.Clean(a.Dir + name)
+
+	if raw, ok := a.Cache.GetStringKey(joined); ok {
+		asset := raw.(*Asset)
+		if fi, err := os.Stat(joined); err == nil && fi.ModTime().Equal(asset.ModTime) {
+			return asset, true
+		}
+	}
 
-	raw, ok := a.Cache.GetStringKey(name)
-	if ok {
-		asset, err := a.Gen(name)
-		return asset, err == nil
+	if a.diskEnabled() {
+		if asset, ok := a.diskGet(joined); ok {
+			if fi, err := os.Stat(joined); err == nil && fi.ModTime().Equal(asset.ModTime) {
+				a.Cache.Set(joined, asset)
+				return asset, true
+			}
+		}
 	}
-	return raw.(*Asset), ok
+
+	asset, err := a.Gen(name)
+	return asset, err == nil
 }
 
 // Del removes an asset, nb. not the file, the file is fine
 func (a *AssetCache) Del(name string) {
 	name = path.Clean(a.Dir + name)
 	a.Cache.Del(name)
+	if a.diskEnabled() {
+		a.diskDel(name)
+	}
+}
+
+// hashPrefixLen is the number of hex characters of an asset's sha256 etag
+// used to fingerprint its URL.
+const hashPrefixLen = 8
+
+// Manifest walks Dir and returns a mapping of logical path (e.g.
+// "/js/app.js") to its fingerprinted URL (e.g. "/js/app.abcd1234.js"),
+// suitable for emitting immutable, far-future-cacheable asset links.
+func (a *AssetCache) Manifest() map[string]string {
+	manifest := map[string]string{}
+
+	filepath.Walk(a.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		logical := filepath.ToSlash(strings.TrimPrefix(p, a.Dir))
+		asset, ok := a.Get(logical)
+		if !ok {
+			return nil
+		}
+
+		manifest[logical] = hashedURL(logical, asset.Etag)
+		return nil
+	})
+
+	return manifest
+}
+
+// URL returns the fingerprinted URL for a logical asset path, for use in
+// templates. It falls back to the logical path unchanged if the asset
+// cannot be found.
+func (a *AssetCache) URL(logical string) string {
+	asset, ok := a.Get(logical)
+	if !ok {
+		return logical
+	}
+	return hashedURL(logical, asset.Etag)
+}
+
+// ServeManifest writes the current Manifest() as JSON, so frontends can
+// look the logical-to-fingerprinted mapping up at runtime.
+func (a *AssetCache) ServeManifest(c *Ctx) error {
+	data, err := json.Marshal(a.Manifest())
+	if err != nil {
+		return err
+	}
+
+	c.SetContentType("application/json")
+	n, err := c.Write(data)
+	if err == nil {
+		c.ContentLength += int64(n)
+	}
+	return err
+}
+
+// hashedURL inserts the first hashPrefixLen hex characters of etag before
+// the logical path's extension.
+func hashedURL(logical, etag string) string {
+	hash := strings.Trim(etag, `"`)
+	if len(hash) > hashPrefixLen {
+		hash = hash[:hashPrefixLen]
+	}
+
+	ext := path.Ext(logical)
+	base := strings.TrimSuffix(logical, ext)
+	return base + "." + hash + ext
+}
+
+// parseHashedURL splits a request path of the form "/js/app.abcd1234.js"
+// back into its logical path ("/js/app.js") and the hash it claims to
+// have, reporting ok = false if p doesn't look fingerprinted.
+func parseHashedURL(p string) (logical, hash string, ok bool) {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+
+	if i := strings.LastIndex(base, "."); i != -1 {
+		if candidate := base[i+1:]; len(candidate) == hashPrefixLen && isHexString(candidate) {
+			return base[:i] + ext, candidate, true
+		}
+	}
+
+	// base held no fingerprint segment; p may fingerprint an extensionless asset,
+	// in which case the hash itself was captured above as ext.
+	if len(ext) == hashPrefixLen+1 && isHexString(ext[1:]) {
+		return base, ext[1:], true
+	}
+
+	return "", "", false
+}
+
+func isHexString(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// diskEnabled reports whether the disk-backed cache tier is configured and
+// not disabled via CacheConfig.MaxAge == 0.
+func (a *AssetCache) diskEnabled() bool {
+	return a.DiskCache.Dir != "" && a.DiskCache.MaxAge != 0
+}
+
+// diskKey derives the hashed on-disk filename for a cleaned asset path.
+func (a *AssetCache) diskKey(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *AssetCache) diskPath(name string) string {
+	return filepath.Join(a.DiskCache.Dir, a.diskKey(name))
+}
+
+// diskLoadIndex scans an existing disk cache directory on startup, building
+// the LRU order from file modification times and evicting anything already
+// over MaxSize.
+func (a *AssetCache) diskLoadIndex() error {
+	entries, err := ioutil.ReadDir(a.DiskCache.Dir)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	a.diskMu.Lock()
+	defer a.diskMu.Unlock()
+
+	for _, fi := range entries {
+		if fi.IsDir() {
+			continue
+		}
+		elem := a.diskOrder.PushBack(&diskLRUEntry{key: fi.Name(), size: fi.Size()})
+		a.diskElems[fi.Name()] = elem
+		a.diskBytes += fi.Size()
+	}
+
+	a.diskEvictLocked()
+
+	return nil
+}
+
+// diskGet reads a cached asset back from the disk tier, honouring
+// CacheConfig.MaxAge and touching its LRU position.
+func (a *AssetCache) diskGet(name string) (*Asset, bool) {
+	key := a.diskKey(name)
+
+	f, err := os.Open(filepath.Join(a.DiskCache.Dir, key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var asset Asset
+	if err := gob.NewDecoder(f).Decode(&asset); err != nil {
+		return nil, false
+	}
+
+	if a.DiskCache.MaxAge > 0 && time.Since(asset.Loaded) > a.DiskCache.MaxAge {
+		a.diskDel(name)
+		return nil, false
+	}
+
+	a.diskMu.Lock()
+	if elem, ok := a.diskElems[key]; ok {
+		a.diskOrder.MoveToBack(elem)
+	}
+	a.diskMu.Unlock()
+
+	return &asset, true
+}
+
+// diskStore persists asset to the disk tier, updating LRU accounting and
+// evicting the least recently used entries if CacheConfig.MaxSize is
+// exceeded.
+func (a *AssetCache) diskStore(name string, asset *Asset) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(asset); err != nil {
+		return err
+	}
+
+	key := a.diskKey(name)
+	if err := ioutil.WriteFile(a.diskPath(name), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	a.diskMu.Lock()
+	defer a.diskMu.Unlock()
+
+	if elem, ok := a.diskElems[key]; ok {
+		entry := elem.Value.(*diskLRUEntry)
+		a.diskBytes -= entry.size
+		a.diskOrder.Remove(elem)
+	}
+
+	entry := &diskLRUEntry{key: key, size: int64(buf.Len())}
+	a.diskElems[key] = a.diskOrder.PushBack(entry)
+	a.diskBytes += entry.size
+
+	a.diskEvictLocked()
+
+	return nil
+}
+
+// diskDel removes a disk tier entry for name, if any.
+func (a *AssetCache) diskDel(name string) {
+	key := a.diskKey(name)
+
+	os.Remove(filepath.Join(a.DiskCache.Dir, key))
+
+	a.diskMu.Lock()
+	defer a.diskMu.Unlock()
+
+	if elem, ok := a.diskElems[key]; ok {
+		entry := elem.Value.(*diskLRUEntry)
+		a.diskBytes -= entry.size
+		a.diskOrder.Remove(elem)
+		delete(a.diskElems, key)
+	}
+}
+
+// diskEvictLocked drops least-recently-used disk entries until the tier
+// fits within CacheConfig.MaxSize. Callers must hold a.diskMu.
+func (a *AssetCache) diskEvictLocked() {
+	if a.DiskCache.MaxSize <= 0 {
+		return
+	}
+
+	for a.diskBytes > a.DiskCache.MaxSize {
+		elem := a.diskOrder.Front()
+		if elem == nil {
+			return
+		}
+
+		entry := elem.Value.(*diskLRUEntry)
+		os.Remove(filepath.Join(a.DiskCache.Dir, entry.key))
+		a.diskOrder.Remove(elem)
+		delete(a.diskElems, entry.key)
+		a.diskBytes -= entry.size
+	}
 }
 
 // Asset is an http servable resource
@@ -206,27 +650,70 @@ type Asset struct {
 
 	Content           []byte
 	ContentCompressed []byte
+	ContentBrotli     []byte
 
 	Etag           string
 	EtagCompressed string
+	EtagBrotli     string
 
 	Compressed bool
 }
 
 // Serve an asset through c *Ctx
 func (as *Asset) Serve(c *Ctx) error {
+	c.SetHeader("accept-ranges", "bytes")
+
+	rangeHeader := c.Header("range")
+
+	enc := "identity"
+	if as.Compressed {
+		c.SetHeader("vary", "Accept-Encoding")
+
+		// RFC 7233: ranges are only meaningful against the representation
+		// without content-coding, so content-encoding negotiation (and
+		// any 406 it could produce) doesn't apply to Range requests.
+		if rangeHeader == "" {
+			enc = negotiateEncoding(c.Header("accept-encoding"), as.ContentBrotli != nil, as.ContentCompressed != nil)
+			if enc == "" {
+				c.W.WriteHeader(http.StatusNotAcceptable)
+				return nil
+			}
+		}
+	}
+
+	etag := as.Etag
+	switch enc {
+	case "br":
+		etag = as.EtagBrotli
+	case "gzip":
+		etag = as.EtagCompressed
+	}
+
 	c.SetContentType(as.ContentType)
 	if c.GetHeader("last-modified") == "" {
 		c.SetHeader("last-modified", as.ModTime.UTC().Format(http.TimeFormat))
 	}
+	c.SetHeader("etag", etag)
+
+	if as.notModified(c, etag) {
+		c.W.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if rangeHeader != "" {
+		return as.serveRange(c, rangeHeader)
+	}
 
 	var n int
 	var err error
-	if as.Compressed && strings.Contains(c.Header("accept-encoding"), "gzip") {
-		c.SetHeader("etag", as.EtagCompressed)
+	switch enc {
+	case "br":
+		c.SetHeader("content-encoding", "br")
+		n, err = c.Write(as.ContentBrotli)
+	case "gzip":
+		c.SetHeader("content-encoding", "gzip")
 		n, err = c.Write(as.ContentCompressed)
-	} else {
-		c.SetHeader("etag", as.Etag)
+	default:
 		n, err = c.Write(as.Content)
 	}
 
@@ -236,6 +723,193 @@ func (as *Asset) Serve(c *Ctx) error {
 	return err
 }
 
+// serveRange handles a single-range Range request against the uncompressed
+// Content, writing 206 Partial Content with the requested slice or 416
+// Requested Range Not Satisfiable when the range is invalid.
+func (as *Asset) serveRange(c *Ctx, rangeHeader string) error {
+	size := int64(len(as.Content))
+
+	start, end, ok := parseRange(rangeHeader, size)
+	if !ok {
+		c.SetHeader("content-range", fmt.Sprintf("bytes */%d", size))
+		c.W.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+
+	c.SetHeader("content-encoding", "identity")
+	c.SetHeader("content-range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	c.W.WriteHeader(http.StatusPartialContent)
+
+	n, err := c.Write(as.Content[start : end+1])
+	if err == nil {
+		c.ContentLength += int64(n)
+	}
+	return err
+}
+
+// parseRange parses a single "bytes=start-end" Range header value against
+// size, returning the inclusive byte bounds to serve. Multi-range requests
+// are not supported and report ok = false.
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, false
+	}
+
+	spec := strings.TrimPrefix(header, prefix)
+	if strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	switch {
+	case parts[0] == "" && parts[1] == "":
+		return 0, 0, false
+
+	case parts[0] == "":
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+
+	case parts[1] == "":
+		start, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || start < 0 || start >= size {
+			return 0, 0, false
+		}
+		return start, size - 1, true
+
+	default:
+		start, err1 := strconv.ParseInt(parts[0], 10, 64)
+		end, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil || start < 0 || end < start || start >= size {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+		return start, end, true
+	}
+}
+
+// negotiateEncoding picks the best content-encoding to serve given the
+// request's Accept-Encoding header, preferring br over gzip over identity,
+// and honouring q-values. Per RFC 7231 §5.3.4, identity is acceptable by
+// default when unlisted, but br/gzip are not - they must be named (or
+// covered by "*") to be used. Returns "" if nothing the client finds
+// acceptable is available, including identity;q=0 refusals - callers
+// should respond 406 Not Acceptable in that case.
+func negotiateEncoding(header string, brAvailable, gzipAvailable bool) string {
+	if header == "" {
+		return "identity"
+	}
+
+	qs := parseAcceptEncoding(header)
+	acceptable := func(enc string) bool {
+		if q, ok := qs[enc]; ok {
+			return q > 0
+		}
+		if q, ok := qs["*"]; ok {
+			return q > 0
+		}
+		return enc == "identity"
+	}
+
+	if brAvailable && acceptable("br") {
+		return "br"
+	}
+	if gzipAvailable && acceptable("gzip") {
+		return "gzip"
+	}
+	if acceptable("identity") {
+		return "identity"
+	}
+	return ""
+}
+
+// parseAcceptEncoding parses an Accept-Encoding header into a map of
+// lowercased coding name to its q-value (defaulting to 1 when unspecified).
+func parseAcceptEncoding(header string) map[string]float64 {
+	qs := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name := part
+		q := 1.0
+
+		if i := strings.Index(part, ";"); i != -1 {
+			name = strings.TrimSpace(part[:i])
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if v, ok := parseQValue(param); ok {
+					q = v
+				}
+			}
+		}
+
+		qs[strings.ToLower(name)] = q
+	}
+
+	return qs
+}
+
+func parseQValue(param string) (float64, bool) {
+	if !strings.HasPrefix(param, "q=") {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// notModified reports whether the request's conditional headers indicate
+// the client already holds a fresh copy of as, given the etag that would
+// be served for the negotiated encoding.
+func (as *Asset) notModified(c *Ctx, etag string) bool {
+	return conditionalHit(c.Header("if-none-match"), c.Header("if-modified-since"), etag, as.ModTime)
+}
+
+// conditionalHit implements the precedence rules for RFC 7232 conditional
+// requests: If-None-Match (exact match, comma-separated list, or "*")
+// takes priority over If-Modified-Since, which is only consulted when
+// If-None-Match is absent.
+func conditionalHit(ifNoneMatch, ifModifiedSince, etag string, modTime time.Time) bool {
+	if ifNoneMatch != "" {
+		if ifNoneMatch == "*" {
+			return true
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			if strings.TrimSpace(candidate) == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince != "" {
+		t, err := time.Parse(http.TimeFormat, ifModifiedSince)
+		if err == nil && !modTime.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func gzipBytes(content []byte, level int) ([]byte, error) {
 	var b bytes.Buffer
 
@@ -254,5 +928,20 @@ func gzipBytes(content []byte, level int) ([]byte, error) {
 		return nil, err
 	}
 
+	return b.Bytes(), nil
+}
+
+func brotliBytes(content []byte, quality int) ([]byte, error) {
+	var b bytes.Buffer
+
+	w := brotli.NewWriterLevel(&b, quality)
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
 	return b.Bytes(), nil
 }
\ No newline at end of file
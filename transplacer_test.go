@@ -0,0 +1,299 @@
+package mak
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		name                      string
+		header                    string
+		brAvailable, gzAvailable bool
+		want                      string
+	}{
+		{"no header prefers identity", "", true, true, "identity"},
+		{"br preferred over gzip", "gzip, br", true, true, "br"},
+		{"gzip only accepted", "gzip", true, true, "gzip"},
+		{"unavailable br falls back to gzip", "br, gzip", false, true, "gzip"},
+		{"unlisted br not used when only gzip named", "gzip", true, true, "gzip"},
+		{"wildcard allows br", "*", true, true, "br"},
+		{"explicit br refusal falls back to gzip", "br;q=0, gzip", true, true, "gzip"},
+		{"explicit gzip refusal falls back to identity", "gzip;q=0", false, true, "identity"},
+		{"identity refused with nothing else acceptable", "identity;q=0", false, false, ""},
+		{"identity refused but br available", "identity;q=0, br", true, true, "br"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := negotiateEncoding(tc.header, tc.brAvailable, tc.gzAvailable)
+			if got != tc.want {
+				t.Errorf("negotiateEncoding(%q, %v, %v) = %q, want %q", tc.header, tc.brAvailable, tc.gzAvailable, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAcceptEncodingQValues(t *testing.T) {
+	qs := parseAcceptEncoding("gzip;q=0.5, br, identity;q=0")
+
+	if qs["gzip"] != 0.5 {
+		t.Errorf("gzip q = %v, want 0.5", qs["gzip"])
+	}
+	if qs["br"] != 1.0 {
+		t.Errorf("br q = %v, want 1.0 (default)", qs["br"])
+	}
+	if qs["identity"] != 0 {
+		t.Errorf("identity q = %v, want 0", qs["identity"])
+	}
+}
+
+func TestDiskCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	mkAsset := func(content string) *Asset {
+		return &Asset{Content: []byte(content), Loaded: time.Now()}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(mkAsset("aaaaa")); err != nil {
+		t.Fatalf("encode sample asset: %v", err)
+	}
+	unit := int64(buf.Len())
+
+	a, err := MakeAssetCache(t.TempDir(), -1, time.Hour, CacheConfig{
+		Dir:     t.TempDir(),
+		MaxAge:  -1,
+		MaxSize: unit*2 + 1, // room for two entries, not three
+	})
+	if err != nil {
+		t.Fatalf("MakeAssetCache: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	if err := a.diskStore("/a", mkAsset("aaaaa")); err != nil {
+		t.Fatalf("diskStore /a: %v", err)
+	}
+	if err := a.diskStore("/b", mkAsset("bbbbb")); err != nil {
+		t.Fatalf("diskStore /b: %v", err)
+	}
+	if err := a.diskStore("/c", mkAsset("ccccc")); err != nil {
+		t.Fatalf("diskStore /c: %v", err)
+	}
+
+	if _, ok := a.diskGet("/a"); ok {
+		t.Error("expected /a to have been evicted as least recently used")
+	}
+	if _, ok := a.diskGet("/c"); !ok {
+		t.Error("expected /c (most recently stored) to still be cached")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	const size = int64(100)
+
+	cases := []struct {
+		name          string
+		header        string
+		wantStart     int64
+		wantEnd       int64
+		wantOK        bool
+	}{
+		{"simple range", "bytes=0-49", 0, 49, true},
+		{"open-ended range", "bytes=50-", 50, 99, true},
+		{"suffix range", "bytes=-10", 90, 99, true},
+		{"suffix longer than size clamps to whole file", "bytes=-1000", 0, 99, true},
+		{"end beyond size clamps to last byte", "bytes=0-1000", 0, 99, true},
+		{"start at or past size is unsatisfiable", "bytes=100-", 0, 0, false},
+		{"end before start is unsatisfiable", "bytes=50-10", 0, 0, false},
+		{"missing unit is unsatisfiable", "0-49", 0, 0, false},
+		{"multi-range is unsupported", "bytes=0-10,20-30", 0, 0, false},
+		{"empty spec is unsatisfiable", "bytes=-", 0, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, ok := parseRange(tc.header, size)
+			if ok != tc.wantOK {
+				t.Fatalf("parseRange(%q, %d) ok = %v, want %v", tc.header, size, ok, tc.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if start != tc.wantStart || end != tc.wantEnd {
+				t.Errorf("parseRange(%q, %d) = (%d, %d), want (%d, %d)", tc.header, size, start, end, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestHashedURLRoundTrip(t *testing.T) {
+	cases := []string{
+		`"abcd1234deadbeef"`,
+		`"ff00ff00"`,
+	}
+
+	logicalPaths := []string{
+		"/js/app.js",
+		"/styles/site.css",
+		"/README", // extensionless
+	}
+
+	for _, logical := range logicalPaths {
+		for _, etag := range cases {
+			url := hashedURL(logical, etag)
+
+			gotLogical, gotHash, ok := parseHashedURL(url)
+			if !ok {
+				t.Fatalf("parseHashedURL(%q) (from hashedURL(%q, %q)) returned ok=false", url, logical, etag)
+			}
+			if gotLogical != logical {
+				t.Errorf("parseHashedURL(%q) logical = %q, want %q", url, gotLogical, logical)
+			}
+
+			wantHash := strings.Trim(etag, `"`)[:hashPrefixLen]
+			if gotHash != wantHash {
+				t.Errorf("parseHashedURL(%q) hash = %q, want %q", url, gotHash, wantHash)
+			}
+		}
+	}
+}
+
+func TestParseHashedURLRejectsNonFingerprintedPaths(t *testing.T) {
+	cases := []string{
+		"/js/app.js",          // no fingerprint segment at all
+		"/deadbeef.css",       // a literal filename, not "<name>.<hash>.ext"
+		"/js/app.gggggggg.js", // segment present but not valid hex
+	}
+
+	for _, p := range cases {
+		if _, _, ok := parseHashedURL(p); ok {
+			t.Errorf("parseHashedURL(%q) = ok, want not-fingerprinted", p)
+		}
+	}
+}
+
+func TestConditionalHit(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	const etag = `"abc123"`
+
+	cases := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		want            bool
+	}{
+		{"no conditional headers", "", "", false},
+		{"exact if-none-match", etag, "", true},
+		{"mismatched if-none-match", `"other"`, "", false},
+		{"wildcard if-none-match", "*", "", true},
+		{"comma separated list matching second entry", `"other", ` + etag, "", true},
+		{"comma separated list with no match", `"a", "b"`, "", false},
+		{"if-modified-since equal to mod time", "", modTime.Format(http.TimeFormat), true},
+		{"if-modified-since after mod time", "", modTime.Add(time.Hour).Format(http.TimeFormat), true},
+		{"if-modified-since before mod time", "", modTime.Add(-time.Hour).Format(http.TimeFormat), false},
+		{"if-none-match takes precedence over a satisfied if-modified-since", `"other"`, modTime.Add(time.Hour).Format(http.TimeFormat), false},
+		{"unparseable if-modified-since", "", "not-a-date", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := conditionalHit(tc.ifNoneMatch, tc.ifModifiedSince, etag, modTime)
+			if got != tc.want {
+				t.Errorf("conditionalHit(%q, %q, etag, modTime) = %v, want %v", tc.ifNoneMatch, tc.ifModifiedSince, got, tc.want)
+			}
+		})
+	}
+}
+
+func mustWriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestHandleWatchEventRegeneratesOnWriteAndCreate(t *testing.T) {
+	for _, op := range []fsnotify.Op{fsnotify.Write, fsnotify.Create} {
+		t.Run(op.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, "file.txt")
+			mustWriteFile(t, filePath, "v1")
+
+			a, err := MakeAssetCache(dir, -1, time.Hour, CacheConfig{})
+			if err != nil {
+				t.Fatalf("MakeAssetCache: %v", err)
+			}
+			t.Cleanup(func() { a.Close() })
+
+			if _, ok := a.Get("/file.txt"); !ok {
+				t.Fatalf("seed Get(/file.txt) = not ok")
+			}
+
+			mustWriteFile(t, filePath, "v2")
+			a.handleWatchEvent(fsnotify.Event{Name: filePath, Op: op})
+
+			joined := path.Clean(a.Dir + "/file.txt")
+			raw, ok := a.Cache.GetStringKey(joined)
+			if !ok {
+				t.Fatalf("expected cache entry to exist after %s event", op)
+			}
+			if got := string(raw.(*Asset).Content); got != "v2" {
+				t.Errorf("cache content after %s event = %q, want v2", op, got)
+			}
+		})
+	}
+}
+
+func TestHandleWatchEventInvalidatesOnRemoveAndRename(t *testing.T) {
+	for _, op := range []fsnotify.Op{fsnotify.Remove, fsnotify.Rename} {
+		t.Run(op.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, "file.txt")
+			mustWriteFile(t, filePath, "v1")
+
+			a, err := MakeAssetCache(dir, -1, time.Hour, CacheConfig{})
+			if err != nil {
+				t.Fatalf("MakeAssetCache: %v", err)
+			}
+			t.Cleanup(func() { a.Close() })
+
+			if _, ok := a.Get("/file.txt"); !ok {
+				t.Fatalf("seed Get(/file.txt) = not ok")
+			}
+
+			a.handleWatchEvent(fsnotify.Event{Name: filePath, Op: op})
+
+			joined := path.Clean(a.Dir + "/file.txt")
+			if _, ok := a.Cache.GetStringKey(joined); ok {
+				t.Errorf("expected cache entry to be removed after %s event", op)
+			}
+		})
+	}
+}
+
+func TestWatchToggle(t *testing.T) {
+	a, err := MakeAssetCache(t.TempDir(), -1, time.Hour, CacheConfig{})
+	if err != nil {
+		t.Fatalf("MakeAssetCache: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	if err := a.Watch(true); err != nil {
+		t.Errorf("Watch(true) on an already-watching cache: %v", err)
+	}
+	if err := a.Watch(false); err != nil {
+		t.Errorf("Watch(false): %v", err)
+	}
+	if err := a.Watch(false); err != nil {
+		t.Errorf("Watch(false) when already stopped: %v", err)
+	}
+}
+